@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package protobuf
+
+// NewAllocatorManual requires cgo to allocate off-heap via C.malloc;
+// without it, it falls back to the heap allocator.
+func NewAllocatorManual() Allocator {
+	return NewAllocatorHeap()
+}
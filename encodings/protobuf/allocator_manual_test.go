@@ -0,0 +1,30 @@
+package protobuf
+
+import "testing"
+
+func TestManualAllocator(t *testing.T) {
+	testallocator(t, NewAllocatorManual())
+}
+
+func TestManualAllocatorStats(t *testing.T) {
+	allocator := NewAllocatorManual()
+	Assert(t, allocator.Stats().LiveBytes == 0)
+
+	buffer := allocator.New(4 << 10)
+	Assert(t, allocator.Stats().LiveBytes == 4<<10)
+
+	allocator.Dispose(buffer)
+	Assert(t, allocator.Stats().LiveBytes == 0)
+}
+
+func BenchmarkManualAllocator1K(b *testing.B) {
+	benchallocator(b, NewAllocatorManual(), 1<<10)
+}
+
+func BenchmarkManualAllocator4K(b *testing.B) {
+	benchallocator(b, NewAllocatorManual(), 4<<10)
+}
+
+func BenchmarkManualAllocator128K(b *testing.B) {
+	benchallocator(b, NewAllocatorManual(), 128<<10)
+}
@@ -0,0 +1,159 @@
+package protobuf
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPoolLimit is returned by MultiPool.New when a class's in-use count
+// would exceed its configured hard cap.
+var ErrPoolLimit = errors.New("protobuf: buffer pool class limit exceeded")
+
+// ClassStatus reports the counters for a single size class of a
+// MultiPool.
+type ClassStatus struct {
+	Size   int
+	Free   int
+	InUse  int
+	Allocs uint64
+	Frees  uint64
+}
+
+// MultiPool is a fixed-capacity buffer pool covering many exact sizes
+// at once, analogous to BufferPool but with a New that returns an error
+// when a class hits its hard cap.
+type MultiPool interface {
+	New(n int) ([]byte, error)
+	Dispose(b []byte)
+	Close()
+	Status() []ClassStatus
+}
+
+// multiClass tracks the free list and counters for one exact buffer
+// size within a multiPool.
+type multiClass struct {
+	size   int
+	limit  int // hard cap on in-use buffers, 0 means unlimited
+	mutex  sync.Mutex
+	free   [][]byte
+	inUse  int64
+	allocs uint64
+	frees  uint64
+}
+
+// multiPool is a MultiPool with one fixed-capacity free list per exact
+// buffer size in classes, analogous to fixedPool but covering many
+// sizes at once.
+type multiPool struct {
+	allocator Allocator
+	classes   map[int]*multiClass
+}
+
+// NewBufferPoolMulti returns a MultiPool with one free list per exact
+// size in classes, where each map value is the free-list capacity for
+// that size. Buffers whose size has no matching class fall through to
+// allocator; New never returns an error.
+func NewBufferPoolMulti(allocator Allocator, classes map[int]int) MultiPool {
+	p := &multiPool{
+		allocator: allocator,
+		classes:   make(map[int]*multiClass, len(classes)),
+	}
+	for size, capacity := range classes {
+		p.classes[size] = &multiClass{
+			size: size,
+			free: make([][]byte, 0, capacity),
+		}
+	}
+	return p
+}
+
+// NewBufferPoolMultiLimited is like NewBufferPoolMulti but additionally
+// enforces a hard cap on the number of in-use buffers per class, given
+// by limits (keyed the same way as classes). New returns ErrPoolLimit
+// once a class's in-use count would exceed its limit; classes absent
+// from limits are unbounded.
+func NewBufferPoolMultiLimited(allocator Allocator, classes map[int]int, limits map[int]int) MultiPool {
+	p := NewBufferPoolMulti(allocator, classes).(*multiPool)
+	for size, limit := range limits {
+		if c, ok := p.classes[size]; ok {
+			c.limit = limit
+		}
+	}
+	return p
+}
+
+func (p *multiPool) New(n int) ([]byte, error) {
+	c, ok := p.classes[n]
+	if !ok {
+		return p.allocator.New(n), nil
+	}
+
+	c.mutex.Lock()
+	if c.limit > 0 && int(c.inUse) >= c.limit {
+		c.mutex.Unlock()
+		return nil, ErrPoolLimit
+	}
+	var b []byte
+	if l := len(c.free); l > 0 {
+		b = c.free[l-1]
+		c.free = c.free[:l-1]
+	}
+	c.inUse++
+	c.mutex.Unlock()
+
+	if b == nil {
+		b = p.allocator.New(n)
+		atomic.AddUint64(&c.allocs, 1)
+	}
+	return b, nil
+}
+
+func (p *multiPool) Dispose(b []byte) {
+	c, ok := p.classes[cap(b)]
+	if !ok {
+		p.allocator.Dispose(b)
+		return
+	}
+
+	atomic.AddUint64(&c.frees, 1)
+	c.mutex.Lock()
+	c.inUse--
+	if len(c.free) < cap(c.free) {
+		c.free = append(c.free, b)
+		c.mutex.Unlock()
+		return
+	}
+	c.mutex.Unlock()
+	p.allocator.Dispose(b)
+}
+
+func (p *multiPool) Close() {
+	for _, c := range p.classes {
+		c.mutex.Lock()
+		free := c.free
+		c.free = c.free[:0]
+		c.inUse = 0
+		c.mutex.Unlock()
+		for _, b := range free {
+			p.allocator.Dispose(b)
+		}
+	}
+}
+
+// Status returns a snapshot of the per-class counters.
+func (p *multiPool) Status() []ClassStatus {
+	status := make([]ClassStatus, 0, len(p.classes))
+	for _, c := range p.classes {
+		c.mutex.Lock()
+		status = append(status, ClassStatus{
+			Size:   c.size,
+			Free:   len(c.free),
+			InUse:  int(c.inUse),
+			Allocs: atomic.LoadUint64(&c.allocs),
+			Frees:  atomic.LoadUint64(&c.frees),
+		})
+		c.mutex.Unlock()
+	}
+	return status
+}
@@ -0,0 +1,126 @@
+package protobuf
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMultiPoolClassSelection(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"small", 64},
+		{"medium", 4 << 10},
+		{"large", 64 << 10},
+	}
+
+	pool := NewBufferPoolMulti(NewAllocatorHeap(), map[int]int{
+		64:       4,
+		4 << 10:  4,
+		64 << 10: 4,
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buffer, err := pool.New(test.size)
+			Assert(t, err == nil)
+			Assert(t, len(buffer) == test.size)
+			pool.Dispose(buffer)
+
+			for _, s := range pool.Status() {
+				if s.Size == test.size {
+					Assert(t, s.Free == 1)
+					Assert(t, s.Allocs == 1)
+					Assert(t, s.Frees == 1)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiPoolUnknownSizeFallsThrough(t *testing.T) {
+	pool := NewBufferPoolMulti(NewAllocatorHeap(), map[int]int{64: 4})
+
+	buffer, err := pool.New(100)
+	Assert(t, err == nil)
+	Assert(t, len(buffer) == 100)
+	pool.Dispose(buffer)
+
+	for _, s := range pool.Status() {
+		Assert(t, s.Size != 100)
+	}
+}
+
+func TestMultiPoolLimitEnforcement(t *testing.T) {
+	const size = 128
+	pool := NewBufferPoolMultiLimited(
+		NewAllocatorHeap(),
+		map[int]int{size: 4},
+		map[int]int{size: 2},
+	)
+
+	first, err := pool.New(size)
+	Assert(t, err == nil)
+	second, err := pool.New(size)
+	Assert(t, err == nil)
+
+	_, err = pool.New(size)
+	Assert(t, err == ErrPoolLimit)
+
+	pool.Dispose(first)
+	third, err := pool.New(size)
+	Assert(t, err == nil)
+
+	pool.Dispose(second)
+	pool.Dispose(third)
+}
+
+func TestMultiPoolStats(t *testing.T) {
+	const size = 256
+	pool := NewBufferPoolMulti(NewAllocatorHeap(), map[int]int{size: 8})
+
+	var buffers [][]byte
+	for k := 0; k < 10; k++ {
+		buffer, err := pool.New(size)
+		Assert(t, err == nil)
+		buffers = append(buffers, buffer)
+	}
+	for _, b := range buffers {
+		pool.Dispose(b)
+	}
+
+	status := pool.Status()
+	Assert(t, len(status) == 1)
+	Assert(t, status[0].Allocs == 10)
+	Assert(t, status[0].Frees == 10)
+	Assert(t, status[0].Free == 8)
+	Assert(t, status[0].InUse == 0)
+
+	pool.Close()
+	status = pool.Status()
+	Assert(t, status[0].Free == 0)
+}
+
+func BenchmarkMultiPoolConcurrent(b *testing.B) {
+	const size = 4 << 10
+	pool := NewBufferPoolMulti(NewAllocatorHeap(), map[int]int{size: 64})
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < b.N; k++ {
+				buffer, err := pool.New(size)
+				if err != nil {
+					continue
+				}
+				pool.Dispose(buffer)
+			}
+		}()
+	}
+	wg.Wait()
+	pool.Close()
+}
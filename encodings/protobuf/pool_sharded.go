@@ -0,0 +1,123 @@
+package protobuf
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// fastrand gives shardedPool a cheap, goroutine-local-ish shard index
+// without its own state or synchronization, reusing the same source of
+// randomness sync.Pool relies on internally.
+//
+//go:linkname fastrand runtime.fastrand
+func fastrand() uint32
+
+// originStripe tracks which shard a subset of outstanding buffers came
+// from, guarded by its own mutex. shardedPool keeps one stripe per
+// shard and picks a stripe by hashing the buffer's data pointer, so
+// New/Dispose calls for distinct buffers only contend with each other
+// when they happen to land in the same stripe, rather than serializing
+// on one pool-wide lock.
+type originStripe struct {
+	mutex sync.Mutex
+	m     map[uintptr]int
+}
+
+// shardedPool fans a BufferPool out across N independent inner pools,
+// each built from the same factory, to eliminate the mutex contention a
+// single shared pool suffers under many concurrent goroutines. New
+// picks a shard via a fast per-call hash and records it in the origin
+// stripes; Dispose always returns a buffer to the shard it actually
+// came from, since some inner pools (e.g. the slab pool's oversize
+// tracking) keep per-instance state that only that exact shard can
+// correctly dispose into. A freshly hashed shard is only used as a
+// fallback for buffers shardedPool never saw in New (outside its
+// contract).
+type shardedPool struct {
+	shards  []BufferPool
+	stripes []originStripe
+}
+
+// NewBufferPoolSharded returns a BufferPool backed by shards independent
+// inner pools built by factory, routing each New/Dispose to reduce
+// contention on any single inner pool. shards <= 0 defaults to
+// runtime.GOMAXPROCS(0).
+func NewBufferPoolSharded(factory func() BufferPool, shards int) BufferPool {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	p := &shardedPool{
+		shards:  make([]BufferPool, shards),
+		stripes: make([]originStripe, shards),
+	}
+	for i := range p.shards {
+		p.shards[i] = factory()
+	}
+	for i := range p.stripes {
+		p.stripes[i].m = make(map[uintptr]int)
+	}
+	return p
+}
+
+func (p *shardedPool) shardIndex() int {
+	return int(fastrand() % uint32(len(p.shards)))
+}
+
+func dataPointer(b []byte) uintptr {
+	if cap(b) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&b[:1][0]))
+}
+
+// stripeFor returns the origin stripe responsible for ptr. Hashing on
+// the buffer's own address, rather than the calling goroutine, means
+// New and Dispose for two unrelated buffers only fight over the same
+// stripe lock when their addresses hash alike. The address is mixed
+// with a multiplicative hash first, so buffers that share their low
+// address bits (e.g. page-aligned ones) still spread across stripes.
+func (p *shardedPool) stripeFor(ptr uintptr) *originStripe {
+	h := uint64(ptr) * 0x9E3779B97F4A7C15
+	return &p.stripes[(h>>32)%uint64(len(p.stripes))]
+}
+
+func (p *shardedPool) New(n int) []byte {
+	i := p.shardIndex()
+	b := p.shards[i].New(n)
+	if ptr := dataPointer(b); ptr != 0 {
+		s := p.stripeFor(ptr)
+		s.mutex.Lock()
+		s.m[ptr] = i
+		s.mutex.Unlock()
+	}
+	return b
+}
+
+func (p *shardedPool) Dispose(b []byte) {
+	ptr := dataPointer(b)
+	shard := p.shardIndex()
+	if ptr != 0 {
+		s := p.stripeFor(ptr)
+		s.mutex.Lock()
+		if origin, tracked := s.m[ptr]; tracked {
+			delete(s.m, ptr)
+			shard = origin
+		}
+		s.mutex.Unlock()
+	}
+	p.shards[shard].Dispose(b)
+}
+
+func (p *shardedPool) Close() {
+	for _, s := range p.shards {
+		s.Close()
+	}
+	for i := range p.stripes {
+		p.stripes[i].mutex.Lock()
+		for k := range p.stripes[i].m {
+			delete(p.stripes[i].m, k)
+		}
+		p.stripes[i].mutex.Unlock()
+	}
+}
@@ -0,0 +1,117 @@
+package protobuf
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestShardedPoolDefaultShardCount(t *testing.T) {
+	pool := NewBufferPoolSharded(func() BufferPool {
+		return NewBufferPoolFixed(NewAllocatorHeap(), 64, 4)
+	}, 0).(*shardedPool)
+	Assert(t, len(pool.shards) == runtime.GOMAXPROCS(0))
+	pool.Close()
+}
+
+func TestShardedPoolNewDispose(t *testing.T) {
+	const size = 64
+	pool := NewBufferPoolSharded(func() BufferPool {
+		return NewBufferPoolFixed(NewAllocatorHeap(), size, 4)
+	}, 4)
+
+	var buffers [][]byte
+	for k := 0; k < 32; k++ {
+		b := pool.New(size)
+		Assert(t, len(b) == size)
+		buffers = append(buffers, b)
+	}
+	for _, b := range buffers {
+		pool.Dispose(b)
+	}
+	pool.Close()
+}
+
+func TestShardedPoolCloseDrainsAllShards(t *testing.T) {
+	const (
+		size     = 64
+		shards   = 4
+		capacity = 8
+	)
+	inner := make([]BufferPool, 0, shards)
+	pool := NewBufferPoolSharded(func() BufferPool {
+		p := NewBufferPoolFixed(NewAllocatorHeap(), size, capacity)
+		inner = append(inner, p)
+		return p
+	}, shards).(*shardedPool)
+	Assert(t, len(inner) == shards)
+
+	for i, s := range inner {
+		b := s.New(size)
+		s.Dispose(b)
+		Assert(t, len(s.(*fixedPool).free) == 1)
+		_ = i
+	}
+
+	pool.Close()
+	for _, s := range inner {
+		Assert(t, len(s.(*fixedPool).free) == 0)
+	}
+}
+
+// TestShardedPoolDisposesToOriginShard guards against regressing to a
+// random-shard Dispose: slabPool's oversize tracking is per-instance,
+// so a buffer disposed into any shard but the one it was allocated
+// from is silently dropped instead of freed.
+func TestShardedPoolDisposesToOriginShard(t *testing.T) {
+	const (
+		slabSize = 4 << 10
+		oversize = slabSize + 1
+		shards   = 8
+		count    = 2000
+	)
+	allocator := NewAllocatorMapped()
+	pool := NewBufferPoolSharded(func() BufferPool {
+		return NewBufferPoolSlab(allocator, slabSize)
+	}, shards)
+
+	var buffers [][]byte
+	for k := 0; k < count; k++ {
+		buffers = append(buffers, pool.New(oversize))
+	}
+	for _, b := range buffers {
+		pool.Dispose(b)
+	}
+
+	Assert(t, allocator.Stats().LiveBytes == 0)
+	pool.Close()
+}
+
+func BenchmarkShardedPoolConcurrent(b *testing.B) {
+	const size = 4 << 10
+	pool := NewBufferPoolSharded(func() BufferPool {
+		return NewBufferPoolFixed(NewAllocatorHeap(), size, 64)
+	}, runtime.GOMAXPROCS(0))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buffer := pool.New(size)
+			pool.Dispose(buffer)
+		}
+	})
+	pool.Close()
+}
+
+func BenchmarkSingleSharedPoolConcurrent(b *testing.B) {
+	const size = 4 << 10
+	pool := NewBufferPoolFixed(NewAllocatorHeap(), size, 64)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buffer := pool.New(size)
+			pool.Dispose(buffer)
+		}
+	})
+	pool.Close()
+}
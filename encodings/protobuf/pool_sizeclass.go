@@ -0,0 +1,99 @@
+package protobuf
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// sizeClassedPool maintains one sync.Pool per power-of-two size class
+// between 1<<minShift and 1<<maxShift. New rounds the requested size up
+// to the next class and reslices the pulled buffer down to the exact
+// length requested while keeping the class's full cap; Dispose returns
+// a buffer to the class matching its cap. Requests larger than
+// 1<<maxShift fall through to the allocator.
+//
+// Because sync.Pool items can be dropped on any GC cycle with no
+// callback, and can't be enumerated to drain on Close, this pool never
+// disposes in-class buffers back to the allocator — harmless for a heap
+// allocator, whose memory the GC reclaims once sync.Pool drops it (only
+// its LiveBytes count stays raised), but a real leak for an off-heap one such as
+// NewAllocatorMapped or NewAllocatorManual, whose memory the GC never
+// reclaims on its own. Use NewBufferPoolSizeClassed with
+// NewAllocatorHeap only; reach for NewBufferPoolMulti or
+// NewBufferPoolFixed when off-heap buffers need reliable reuse/release.
+type sizeClassedPool struct {
+	allocator Allocator
+	minShift  uint
+	maxShift  uint
+	classes   []sync.Pool
+}
+
+// NewBufferPoolSizeClassed returns a BufferPool that buckets buffers
+// into power-of-two size classes from 1<<minShift to 1<<maxShift,
+// pooling each class independently via a sync.Pool. allocator should be
+// NewAllocatorHeap(); see the sizeClassedPool doc comment for why
+// off-heap allocators aren't safe here.
+func NewBufferPoolSizeClassed(allocator Allocator, minShift, maxShift uint) BufferPool {
+	p := &sizeClassedPool{
+		allocator: allocator,
+		minShift:  minShift,
+		maxShift:  maxShift,
+		classes:   make([]sync.Pool, maxShift-minShift+1),
+	}
+	for i := range p.classes {
+		shift := minShift + uint(i)
+		p.classes[i].New = func() interface{} {
+			return allocator.New(1 << shift)
+		}
+	}
+	return p
+}
+
+// isPow2 reports whether n is a positive power of two.
+func isPow2(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// classShift returns the shift of the smallest power of two >= n.
+func classShift(n int) uint {
+	if n <= 1 {
+		return 0
+	}
+	return uint(bits.Len(uint(n - 1)))
+}
+
+func (p *sizeClassedPool) New(n int) []byte {
+	shift := classShift(n)
+	if shift < p.minShift {
+		shift = p.minShift
+	}
+	if shift > p.maxShift {
+		return p.allocator.New(n)
+	}
+	b := p.classes[shift-p.minShift].Get().([]byte)
+	return b[:n]
+}
+
+// Dispose returns b to the size class matching its cap. Buffers that
+// don't belong to any class — notably ones New handed back straight
+// from the allocator because they were larger than 1<<maxShift — are
+// passed on to the allocator instead of being dropped on the floor.
+func (p *sizeClassedPool) Dispose(b []byte) {
+	c := cap(b)
+	if isPow2(c) {
+		shift := uint(bits.Len(uint(c)) - 1)
+		if shift >= p.minShift && shift <= p.maxShift {
+			p.classes[shift-p.minShift].Put(b[:c])
+			return
+		}
+	}
+	p.allocator.Dispose(b)
+}
+
+// Close is a no-op: sync.Pool offers no way to enumerate or drain the
+// buffers its classes are currently holding. That's fine for the heap
+// allocator this pool is documented for, whose memory the GC reclaims
+// without a Dispose, but it means Close cannot release memory for any
+// off-heap allocator — another reason not to use one here.
+func (p *sizeClassedPool) Close() {
+}
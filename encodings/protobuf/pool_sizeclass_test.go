@@ -0,0 +1,87 @@
+package protobuf
+
+import "testing"
+
+func TestSizeClassedPoolRounding(t *testing.T) {
+	pool := NewBufferPoolSizeClassed(NewAllocatorHeap(), 0, 16)
+
+	cases := []struct {
+		request int
+		wantCap int
+	}{
+		{1, 1},
+		{2, 2},
+		{3, 4},
+		{4, 4},
+		{5, 8},
+		{1000, 1024},
+		{1025, 2048},
+		{1 << 16, 1 << 16},
+	}
+	for _, c := range cases {
+		buffer := pool.New(c.request)
+		Assert(t, len(buffer) == c.request)
+		Assert(t, cap(buffer) == c.wantCap)
+		pool.Dispose(buffer)
+	}
+}
+
+func TestSizeClassedPoolOversizePassthrough(t *testing.T) {
+	const (
+		minShift = 0
+		maxShift = 10 // 1 << 10 == 1024
+	)
+	allocator := NewAllocatorMapped()
+	pool := NewBufferPoolSizeClassed(allocator, minShift, maxShift)
+
+	buffer := pool.New((1 << maxShift) + 1)
+	Assert(t, len(buffer) == (1<<maxShift)+1)
+	Assert(t, allocator.Stats().LiveBytes == int64(len(buffer)))
+
+	pool.Dispose(buffer)
+	Assert(t, allocator.Stats().LiveBytes == 0)
+}
+
+// TestSizeClassedPoolUsesAllocator uses the heap allocator rather than
+// an off-heap one: in-class buffers live inside a sync.Pool, which can
+// drop them on any GC without notice, so only the heap allocator (whose
+// memory the GC reclaims without a Dispose) is safe to verify this way. See the
+// sizeClassedPool doc comment.
+func TestSizeClassedPoolUsesAllocator(t *testing.T) {
+	allocator := NewAllocatorHeap()
+	pool := NewBufferPoolSizeClassed(allocator, 0, 20)
+
+	buffer := pool.New(4 << 10)
+	Assert(t, allocator.Stats().LiveBytes == int64(cap(buffer)))
+	pool.Dispose(buffer)
+}
+
+func TestSizeClassedPoolGetPutGetSameCap(t *testing.T) {
+	pool := NewBufferPoolSizeClassed(NewAllocatorHeap(), 0, 20)
+
+	buffer := pool.New(4 << 10)
+	wantCap := cap(buffer)
+	pool.Dispose(buffer)
+
+	buffer2 := pool.New(4 << 10)
+	Assert(t, cap(buffer2) == wantCap)
+	pool.Dispose(buffer2)
+}
+
+func benchsizeclassedpool(b *testing.B, size int) {
+	pool := NewBufferPoolSizeClassed(NewAllocatorHeap(), 0, 20)
+	benchbufferpool(b, pool, size)
+	pool.Close()
+}
+
+func BenchmarkSizeClassedPool1K(b *testing.B) {
+	benchsizeclassedpool(b, 1<<10)
+}
+
+func BenchmarkSizeClassedPool4K(b *testing.B) {
+	benchsizeclassedpool(b, 4<<10)
+}
+
+func BenchmarkSizeClassedPool128K(b *testing.B) {
+	benchsizeclassedpool(b, 128<<10)
+}
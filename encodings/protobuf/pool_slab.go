@@ -0,0 +1,119 @@
+package protobuf
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// slabPool hands out sub-slices of large mmap'd slabs via a bump
+// allocator, rather than round-tripping every New to the Allocator.
+// Dispose is a no-op for sub-slices: individual frees don't matter for
+// arena-style allocation where a whole batch is discarded together, as
+// in BR/Lightning's encoding of KV pairs. The slab backing store is
+// only released back to the allocator when Close is called; Reset
+// rewinds the bump pointer so already-allocated slabs are reused by
+// the next batch instead of being freed and re-requested. Requests
+// larger than slabSize don't fit the bump scheme at all and are
+// allocated, tracked and disposed individually instead.
+type slabPool struct {
+	mutex     sync.Mutex
+	allocator Allocator
+	slabSize  int
+	slabs     [][]byte
+	index     int
+	offset    int
+	oversize  map[uintptr][]byte
+}
+
+// NewBufferPoolSlab returns a BufferPool that sub-allocates out of
+// slabSize-byte slabs obtained from allocator, intended for
+// NewAllocatorMapped(). Requests larger than slabSize fall through to
+// the allocator directly.
+func NewBufferPoolSlab(allocator Allocator, slabSize int) BufferPool {
+	return &slabPool{
+		allocator: allocator,
+		slabSize:  slabSize,
+		oversize:  make(map[uintptr][]byte),
+	}
+}
+
+func slabDataPointer(b []byte) uintptr {
+	if cap(b) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&b[:1][0]))
+}
+
+func (p *slabPool) New(n int) []byte {
+	if n > p.slabSize {
+		b := p.allocator.New(n)
+		if ptr := slabDataPointer(b); ptr != 0 {
+			p.mutex.Lock()
+			p.oversize[ptr] = b
+			p.mutex.Unlock()
+		}
+		return b
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for p.index < len(p.slabs) && p.offset+n > len(p.slabs[p.index]) {
+		p.index++
+		p.offset = 0
+	}
+	if p.index == len(p.slabs) {
+		p.slabs = append(p.slabs, p.allocator.New(p.slabSize))
+	}
+	slab := p.slabs[p.index]
+	b := slab[p.offset : p.offset+n : p.offset+n]
+	p.offset += n
+	return b
+}
+
+// Dispose is a no-op for slab sub-slices, which are only released in
+// bulk via Reset or Close. Buffers New allocated individually because
+// they were larger than slabSize are recognized by their data pointer
+// and returned to the allocator immediately.
+func (p *slabPool) Dispose(b []byte) {
+	ptr := slabDataPointer(b)
+	if ptr == 0 {
+		return
+	}
+	p.mutex.Lock()
+	oversize, ok := p.oversize[ptr]
+	if ok {
+		delete(p.oversize, ptr)
+	}
+	p.mutex.Unlock()
+	if ok {
+		p.allocator.Dispose(oversize)
+	}
+}
+
+// Reset rewinds the bump pointer to the start of the first slab,
+// making every byte of every previously allocated slab available for
+// reuse by the next batch without touching the allocator.
+func (p *slabPool) Reset() {
+	p.mutex.Lock()
+	p.index = 0
+	p.offset = 0
+	p.mutex.Unlock()
+}
+
+func (p *slabPool) Close() {
+	p.mutex.Lock()
+	slabs := p.slabs
+	p.slabs = nil
+	p.index = 0
+	p.offset = 0
+	oversize := p.oversize
+	p.oversize = make(map[uintptr][]byte)
+	p.mutex.Unlock()
+
+	for _, s := range slabs {
+		p.allocator.Dispose(s)
+	}
+	for _, b := range oversize {
+		p.allocator.Dispose(b)
+	}
+}
@@ -0,0 +1,110 @@
+package protobuf
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestSlabPoolAdjacency(t *testing.T) {
+	const slabSize = 4 << 10
+	pool := NewBufferPoolSlab(NewAllocatorHeap(), slabSize).(*slabPool)
+
+	a := pool.New(16)
+	b := pool.New(32)
+	Assert(t, len(a) == 16)
+	Assert(t, len(b) == 32)
+	Assert(t, uintptr(unsafe.Pointer(&b[0])) == uintptr(unsafe.Pointer(&a[0]))+16)
+
+	// A request that doesn't fit in the remainder of the current slab
+	// starts a fresh one.
+	c := pool.New(slabSize)
+	Assert(t, len(pool.slabs) == 2)
+	Assert(t, len(c) == slabSize)
+}
+
+func TestSlabPoolReset(t *testing.T) {
+	const slabSize = 4 << 10
+	pool := NewBufferPoolSlab(NewAllocatorHeap(), slabSize).(*slabPool)
+
+	first := pool.New(16)
+	pool.Reset()
+	Assert(t, len(pool.slabs) == 1)
+	Assert(t, pool.index == 0)
+	Assert(t, pool.offset == 0)
+
+	second := pool.New(16)
+	Assert(t, unsafe.Pointer(&first[0]) == unsafe.Pointer(&second[0]))
+}
+
+func TestSlabPoolClose(t *testing.T) {
+	const slabSize = 4 << 10
+	pool := NewBufferPoolSlab(NewAllocatorHeap(), slabSize).(*slabPool)
+
+	pool.New(16)
+	pool.New(slabSize)
+	Assert(t, len(pool.slabs) == 2)
+
+	pool.Close()
+	Assert(t, len(pool.slabs) == 0)
+}
+
+func TestSlabPoolOversizePassthrough(t *testing.T) {
+	const slabSize = 4 << 10
+	allocator := NewAllocatorMapped()
+	pool := NewBufferPoolSlab(allocator, slabSize).(*slabPool)
+
+	buffer := pool.New(slabSize + 1)
+	Assert(t, len(buffer) == slabSize+1)
+	Assert(t, len(pool.slabs) == 0)
+	Assert(t, allocator.Stats().LiveBytes == int64(len(buffer)))
+
+	pool.Dispose(buffer)
+	Assert(t, allocator.Stats().LiveBytes == 0)
+}
+
+func TestSlabPoolOversizeReleasedOnClose(t *testing.T) {
+	const slabSize = 4 << 10
+	allocator := NewAllocatorMapped()
+	pool := NewBufferPoolSlab(allocator, slabSize).(*slabPool)
+
+	pool.New(16)
+	pool.New(slabSize + 1) // never disposed
+	Assert(t, allocator.Stats().LiveBytes > 0)
+
+	pool.Close()
+	Assert(t, allocator.Stats().LiveBytes == 0)
+}
+
+// benchManySmall allocates many small buffers per op, mimicking a batch
+// of encoded KV pairs. pool.Dispose is invoked so the comparison is
+// fair to BufferPool implementations that actually recycle on Dispose;
+// slabPool's Dispose is a no-op and it bulk-releases via Reset instead.
+func benchManySmall(b *testing.B, pool BufferPool) {
+	const (
+		size     = 64
+		perBatch = 64
+	)
+	b.ResetTimer()
+	for k := 0; k < b.N; k++ {
+		for j := 0; j < perBatch; j++ {
+			buffer := pool.New(size)
+			pool.Dispose(buffer)
+		}
+		if slab, ok := pool.(*slabPool); ok {
+			slab.Reset()
+		}
+	}
+	pool.Close()
+}
+
+func BenchmarkSlabPoolManySmall(b *testing.B) {
+	benchManySmall(b, NewBufferPoolSlab(NewAllocatorMapped(), 1<<20))
+}
+
+func BenchmarkSinglePoolManySmall(b *testing.B) {
+	benchManySmall(b, NewBufferPoolSingle(NewAllocatorMapped()))
+}
+
+func BenchmarkFixedPoolManySmall(b *testing.B) {
+	benchManySmall(b, NewBufferPoolFixed(NewAllocatorMapped(), 64, 32))
+}